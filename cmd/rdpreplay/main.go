@@ -0,0 +1,124 @@
+// Command rdpreplay re-emits a recording produced by proxy.Handle's
+// recording option (see internal/record), either to validate it or to
+// replay it to a WebSocket client at its original pace for offline
+// rendering with iron-remote-gui.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/djosix/IronRDP-Proxy-Go/internal/record"
+	"github.com/gorilla/websocket"
+)
+
+func main() {
+	verify := flag.Bool("verify", false, "validate the recording instead of replaying it")
+	listen := flag.String("listen", ":4568", "address to serve a replay WebSocket on")
+	speed := flag.Float64("speed", 1.0, "replay speed multiplier (2.0 = twice as fast)")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: rdpreplay [-verify] [-listen addr] [-speed x] <recording-file>")
+		os.Exit(2)
+	}
+	path := flag.Arg(0)
+
+	if *verify {
+		if err := runVerify(path); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := runServe(path, *listen, *speed); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runVerify(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := record.Verify(f)
+	if err != nil {
+		return fmt.Errorf("recording invalid after %d good frame(s): %w", n, err)
+	}
+	fmt.Printf("ok: %d frames verified\n", n)
+	return nil
+}
+
+// runServe listens on addr and replays the recording at path, honoring
+// speed, to every WebSocket client that connects.
+func runServe(path, addr string, speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("speed must be positive, got %v", speed)
+	}
+
+	upgrader := websocket.Upgrader{}
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		defer ws.Close()
+
+		log.Printf("replaying %s to %s", path, r.RemoteAddr)
+		if err := replayTo(ws, path, speed); err != nil {
+			log.Printf("replay: %v", err)
+		}
+	})
+
+	log.Println("rdpreplay listening on", addr)
+	return http.ListenAndServe(addr, nil)
+}
+
+// replayTo streams the server->client frames of the recording at path to
+// ws, preserving inter-frame timing scaled by speed. Client->server frames
+// are skipped: a replay has no live browser driving input, only a viewer
+// watching server output.
+func replayTo(ws *websocket.Conn, path string, speed float64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rr, err := record.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	var lastTimestampNs uint64
+	for {
+		frame, err := rr.ReadFrame()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if frame.Direction != record.ServerToClient {
+			continue
+		}
+
+		if wait := time.Duration(float64(frame.TimestampNs-lastTimestampNs) / speed); wait > 0 {
+			time.Sleep(wait)
+		}
+		lastTimestampNs = frame.TimestampNs
+
+		if err := ws.WriteMessage(websocket.BinaryMessage, frame.Payload); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Package credssp implements just enough of MS-CSSP (Credential Security
+// Support Provider) and the NTLMv2 authentication it carries for the proxy
+// to complete Network Level Authentication (NLA) against an RDP server on
+// the client's behalf: NEGOTIATE/CHALLENGE/AUTHENTICATE, followed by the
+// pubKeyAuth channel-binding round trip (MS-CSSP 3.1.5, steps 2-3) that
+// binds the NTLM exchange to the server's TLS certificate. It does not
+// implement authInfo (delegating TSCredentials to the server), which this
+// proxy has no need for since it never logs into the server as the user.
+package credssp
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// NTLM negotiate flags, MS-NLMP 2.2.2.5. Only the subset this package sets
+// or inspects is named.
+const (
+	NTLMSSPNegotiateUnicode            uint32 = 0x00000001
+	NTLMSSPRequestTarget               uint32 = 0x00000004
+	NTLMSSPNegotiateSign               uint32 = 0x00000010
+	NTLMSSPNegotiateSeal               uint32 = 0x00000020
+	NTLMSSPNegotiateNTLM               uint32 = 0x00000200
+	NTLMSSPNegotiateOEMDomainSupplied  uint32 = 0x00001000
+	NTLMSSPNegotiateAlwaysSign         uint32 = 0x00008000
+	NTLMSSPTargetTypeServer            uint32 = 0x00020000
+	NTLMSSPNegotiateExtendedSessionSec uint32 = 0x00080000
+	NTLMSSPNegotiateTargetInfo         uint32 = 0x00800000
+	NTLMSSPNegotiateVersion            uint32 = 0x02000000
+	NTLMSSPNegotiate128                uint32 = 0x20000000
+	NTLMSSPNegotiateKeyExch            uint32 = 0x40000000
+	NTLMSSPNegotiate56                 uint32 = 0x80000000
+)
+
+// DefaultNegotiateFlags is what Negotiator sends in the NEGOTIATE_MESSAGE.
+// Sign/Seal/KeyExch/128 are required for the pubKeyAuth step: CredSSP wraps
+// the server's public key with the NTLM session (MS-NLMP 3.4.3), which only
+// exists once key exchange is negotiated.
+const DefaultNegotiateFlags = NTLMSSPNegotiateUnicode |
+	NTLMSSPRequestTarget |
+	NTLMSSPNegotiateSign |
+	NTLMSSPNegotiateSeal |
+	NTLMSSPNegotiateNTLM |
+	NTLMSSPNegotiateAlwaysSign |
+	NTLMSSPNegotiateExtendedSessionSec |
+	NTLMSSPNegotiate128 |
+	NTLMSSPNegotiateKeyExch
+
+var ntlmSignature = [8]byte{'N', 'T', 'L', 'M', 'S', 'S', 'P', 0}
+
+var errMalformedMessage = errors.New("credssp: malformed NTLM message")
+
+// utf16le encodes s as UTF-16LE, the wire encoding NTLM uses throughout.
+func utf16le(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[i*2:], u)
+	}
+	return b
+}
+
+// NegotiateMessage builds a Type 1 NTLM NEGOTIATE_MESSAGE (MS-NLMP 2.2.1.1).
+// The domain is not carried here: NTLMSSPNegotiateOEMDomainSupplied means
+// the DomainName field is OEM (single-byte) encoded, but this package only
+// ever produces Unicode strings, so setting that flag while filling the
+// field with UTF-16 would leave every other byte 0x00 on the wire for a
+// real NTLM peer to misparse. The domain is carried correctly instead in
+// the AUTHENTICATE message's Unicode DomainName field, so it is simply
+// omitted from NEGOTIATE, as real NTLM clients commonly do.
+func NegotiateMessage() []byte {
+	const headerLen = 32
+	msg := make([]byte, headerLen)
+	copy(msg[0:8], ntlmSignature[:])
+	binary.LittleEndian.PutUint32(msg[8:12], 1) // MessageType
+	binary.LittleEndian.PutUint32(msg[12:16], DefaultNegotiateFlags)
+	putFieldDescriptor(msg[16:24], 0, headerLen) // domain: omitted
+	putFieldDescriptor(msg[24:32], 0, headerLen) // workstation: omitted
+
+	return msg
+}
+
+// ChallengeMessage is the parsed form of a Type 2 NTLM CHALLENGE_MESSAGE.
+type ChallengeMessage struct {
+	TargetName      []byte
+	NegotiateFlags  uint32
+	ServerChallenge [8]byte
+	TargetInfo      []byte
+}
+
+// ParseChallengeMessage decodes a Type 2 NTLM CHALLENGE_MESSAGE
+// (MS-NLMP 2.2.1.2).
+func ParseChallengeMessage(b []byte) (*ChallengeMessage, error) {
+	if len(b) < 48 {
+		return nil, errMalformedMessage
+	}
+	if string(b[0:8]) != string(ntlmSignature[:]) {
+		return nil, errMalformedMessage
+	}
+	if binary.LittleEndian.Uint32(b[8:12]) != 2 {
+		return nil, fmt.Errorf("%w: expected message type 2", errMalformedMessage)
+	}
+
+	msg := &ChallengeMessage{
+		NegotiateFlags: binary.LittleEndian.Uint32(b[20:24]),
+	}
+	copy(msg.ServerChallenge[:], b[24:32])
+
+	targetName, err := readField(b, b[12:20])
+	if err != nil {
+		return nil, fmt.Errorf("credssp: challenge target name: %w", err)
+	}
+	msg.TargetName = targetName
+
+	if msg.NegotiateFlags&NTLMSSPNegotiateTargetInfo != 0 {
+		if len(b) < 48 {
+			return nil, errMalformedMessage
+		}
+		targetInfo, err := readField(b, b[40:48])
+		if err != nil {
+			return nil, fmt.Errorf("credssp: challenge target info: %w", err)
+		}
+		msg.TargetInfo = targetInfo
+	}
+
+	return msg, nil
+}
+
+// AuthenticateMessage builds a Type 3 NTLM AUTHENTICATE_MESSAGE
+// (MS-NLMP 2.2.1.3) carrying an NTLMv2 response. lmChallengeResponse feeds
+// the key exchange key derivation (MS-NLMP 3.4.5.2) even though this
+// package never validates an LM response of its own; encryptedRandomSessionKey
+// carries the session key the pubKeyAuth step needs, since
+// NTLMSSPNegotiateKeyExch is always negotiated here.
+func AuthenticateMessage(user, domain string, ntChallengeResponse, lmChallengeResponse, encryptedRandomSessionKey []byte) []byte {
+	userBytes := utf16le(user)
+	domainBytes := utf16le(domain)
+
+	const headerLen = 64
+	offset := headerLen
+	msg := make([]byte, headerLen)
+	copy(msg[0:8], ntlmSignature[:])
+	binary.LittleEndian.PutUint32(msg[8:12], 3) // MessageType
+	binary.LittleEndian.PutUint32(msg[60:64], DefaultNegotiateFlags)
+
+	lmStart := offset
+	msg = append(msg, lmChallengeResponse...)
+	offset += len(lmChallengeResponse)
+	putFieldDescriptor(msg[12:20], len(lmChallengeResponse), lmStart)
+
+	ntStart := offset
+	msg = append(msg, ntChallengeResponse...)
+	offset += len(ntChallengeResponse)
+	putFieldDescriptor(msg[20:28], len(ntChallengeResponse), ntStart)
+
+	domainStart := offset
+	msg = append(msg, domainBytes...)
+	offset += len(domainBytes)
+	putFieldDescriptor(msg[28:36], len(domainBytes), domainStart)
+
+	userStart := offset
+	msg = append(msg, userBytes...)
+	offset += len(userBytes)
+	putFieldDescriptor(msg[36:44], len(userBytes), userStart)
+
+	putFieldDescriptor(msg[44:52], 0, offset) // Workstation: omitted
+
+	sessionKeyStart := offset
+	msg = append(msg, encryptedRandomSessionKey...)
+	offset += len(encryptedRandomSessionKey)
+	putFieldDescriptor(msg[52:60], len(encryptedRandomSessionKey), sessionKeyStart)
+
+	return msg
+}
+
+// putFieldDescriptor writes an NTLM "Len/MaxLen/Offset" field descriptor
+// (8 bytes) describing a payload region of length n starting at offset.
+func putFieldDescriptor(dst []byte, n, offset int) {
+	binary.LittleEndian.PutUint16(dst[0:2], uint16(n))
+	binary.LittleEndian.PutUint16(dst[2:4], uint16(n))
+	binary.LittleEndian.PutUint32(dst[4:8], uint32(offset))
+}
+
+// readField reads the payload region described by an 8-byte field
+// descriptor located at fieldDescriptor within the full message b.
+func readField(b []byte, fieldDescriptor []byte) ([]byte, error) {
+	length := binary.LittleEndian.Uint16(fieldDescriptor[0:2])
+	offset := binary.LittleEndian.Uint32(fieldDescriptor[4:8])
+	end := uint64(offset) + uint64(length)
+	if end > uint64(len(b)) {
+		return nil, errMalformedMessage
+	}
+	return b[offset:end], nil
+}
+
+// NTOWFv2 computes the NTLMv2 key derived from the user's password, per
+// MS-NLMP 3.3.2: HMAC-MD5(MD4(UTF16(password)), UTF16(UPPER(user)+domain)).
+func NTOWFv2(password, user, domain string) []byte {
+	h := md4.New()
+	h.Write(utf16le(password))
+	ntlmHash := h.Sum(nil)
+
+	mac := hmac.New(md5.New, ntlmHash)
+	mac.Write(utf16le(upperASCII(user) + domain))
+	return mac.Sum(nil)
+}
+
+// upperASCII upper-cases ASCII letters only, matching the behavior callers
+// rely on for NTLM usernames (non-ASCII usernames are rare in practice and
+// MS-NLMP leaves their casing rules to the implementation).
+func upperASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'a' && c <= 'z' {
+			b[i] = c - ('a' - 'A')
+		}
+	}
+	return string(b)
+}
+
+// NTLMv2Response is the result of computing an NTLMv2 challenge response:
+// the bytes to place in the AUTHENTICATE message's NtChallengeResponse
+// field, and the session base key derived alongside it.
+type NTLMv2Response struct {
+	NTChallengeResponse []byte
+	SessionBaseKey      []byte
+}
+
+// ComputeNTLMv2Response implements the NTLMv2 response computation of
+// MS-NLMP 3.3.2. clientChallenge must be 8 random bytes; timestamp is the
+// number of 100ns intervals since 1601-01-01, as carried in the response
+// "temp" blob.
+func ComputeNTLMv2Response(ntowfv2, serverChallenge, clientChallenge []byte, timestamp uint64, targetInfo []byte) *NTLMv2Response {
+	temp := make([]byte, 0, 28+len(targetInfo)+4)
+	temp = append(temp, 0x01, 0x01) // RespType, HiRespType
+	temp = append(temp, make([]byte, 6)...)
+	timeBuf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(timeBuf, timestamp)
+	temp = append(temp, timeBuf...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, make([]byte, 4)...)
+	temp = append(temp, targetInfo...)
+	temp = append(temp, make([]byte, 4)...)
+
+	mac := hmac.New(md5.New, ntowfv2)
+	mac.Write(serverChallenge)
+	mac.Write(temp)
+	ntProofStr := mac.Sum(nil)
+
+	sessionMac := hmac.New(md5.New, ntowfv2)
+	sessionMac.Write(ntProofStr)
+
+	return &NTLMv2Response{
+		NTChallengeResponse: append(append([]byte{}, ntProofStr...), temp...),
+		SessionBaseKey:      sessionMac.Sum(nil),
+	}
+}
+
+// ComputeLMv2Response implements the NTLMv2 LMChallengeResponse computation
+// of MS-NLMP 3.3.2: HMAC-MD5(ntowfv2, ServerChallenge || ClientChallenge),
+// followed by ClientChallenge itself. This package does not rely on the
+// server validating it, but the key exchange key derivation below needs its
+// first 8 bytes regardless of whether the server checks the rest.
+func ComputeLMv2Response(ntowfv2, serverChallenge, clientChallenge []byte) []byte {
+	mac := hmac.New(md5.New, ntowfv2)
+	mac.Write(serverChallenge)
+	mac.Write(clientChallenge)
+	return append(mac.Sum(nil), clientChallenge...)
+}
+
+// computeKeyExchangeKey implements the MS-NLMP 3.4.5.2 key exchange key
+// derivation for the case this package always negotiates (Extended Session
+// Security set, LM Key not set): HMAC-MD5(SessionBaseKey, ServerChallenge
+// || LMChallengeResponse[0:8]).
+func computeKeyExchangeKey(sessionBaseKey, serverChallenge, lmChallengeResponsePrefix []byte) []byte {
+	mac := hmac.New(md5.New, sessionBaseKey)
+	mac.Write(serverChallenge)
+	mac.Write(lmChallengeResponsePrefix)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,162 @@
+package credssp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestNegotiateMessage(t *testing.T) {
+	msg := NegotiateMessage()
+
+	if !bytes.Equal(msg[0:8], ntlmSignature[:]) {
+		t.Fatalf("unexpected signature: %x", msg[0:8])
+	}
+	if mt := binary.LittleEndian.Uint32(msg[8:12]); mt != 1 {
+		t.Fatalf("message type = %d, want 1", mt)
+	}
+
+	flags := binary.LittleEndian.Uint32(msg[12:16])
+	for _, want := range []uint32{
+		NTLMSSPNegotiateUnicode,
+		NTLMSSPRequestTarget,
+		NTLMSSPNegotiateNTLM,
+		NTLMSSPNegotiateAlwaysSign,
+		NTLMSSPNegotiateExtendedSessionSec,
+	} {
+		if flags&want == 0 {
+			t.Errorf("flags %#x missing required bit %#x", flags, want)
+		}
+	}
+	if flags&NTLMSSPNegotiateOEMDomainSupplied != 0 {
+		t.Errorf("flags %#x sets NTLMSSPNegotiateOEMDomainSupplied, but no OEM-encoded domain is supplied", flags)
+	}
+
+	domain, err := readField(msg, msg[16:24])
+	if err != nil {
+		t.Fatalf("read domain field: %v", err)
+	}
+	if len(domain) != 0 {
+		t.Fatalf("domain field = %q, want empty", domain)
+	}
+}
+
+func TestParseChallengeMessage(t *testing.T) {
+	target := utf16le("SERVER")
+	targetInfo := []byte{0x00, 0x00, 0x00, 0x00} // AV_PAIR terminator only
+
+	const headerLen = 48
+	msg := make([]byte, headerLen)
+	copy(msg[0:8], ntlmSignature[:])
+	binary.LittleEndian.PutUint32(msg[8:12], 2)
+	putFieldDescriptor(msg[12:20], len(target), headerLen)
+	binary.LittleEndian.PutUint32(msg[20:24], NTLMSSPNegotiateTargetInfo)
+	copy(msg[24:32], []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	putFieldDescriptor(msg[40:48], len(targetInfo), headerLen+len(target))
+	msg = append(msg, target...)
+	msg = append(msg, targetInfo...)
+
+	parsed, err := ParseChallengeMessage(msg)
+	if err != nil {
+		t.Fatalf("ParseChallengeMessage: %v", err)
+	}
+	if string(utf16leDecode(parsed.TargetName)) != "SERVER" {
+		t.Errorf("target name = %q, want %q", parsed.TargetName, "SERVER")
+	}
+	if !bytes.Equal(parsed.ServerChallenge[:], []byte{1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Errorf("server challenge = %x", parsed.ServerChallenge)
+	}
+	if !bytes.Equal(parsed.TargetInfo, targetInfo) {
+		t.Errorf("target info = %x, want %x", parsed.TargetInfo, targetInfo)
+	}
+}
+
+func TestNTOWFv2Deterministic(t *testing.T) {
+	a := NTOWFv2("Password1", "User", "DOMAIN")
+	b := NTOWFv2("Password1", "User", "DOMAIN")
+	if !bytes.Equal(a, b) {
+		t.Fatal("NTOWFv2 is not deterministic for identical inputs")
+	}
+	if len(a) != 16 {
+		t.Fatalf("NTOWFv2 length = %d, want 16", len(a))
+	}
+
+	c := NTOWFv2("Password2", "User", "DOMAIN")
+	if bytes.Equal(a, c) {
+		t.Fatal("NTOWFv2 did not change with a different password")
+	}
+}
+
+func TestComputeNTLMv2Response(t *testing.T) {
+	ntowfv2 := NTOWFv2("Password1", "User", "DOMAIN")
+	serverChallenge := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	clientChallenge := []byte{8, 7, 6, 5, 4, 3, 2, 1}
+	targetInfo := []byte{0x00, 0x00, 0x00, 0x00}
+
+	resp := ComputeNTLMv2Response(ntowfv2, serverChallenge, clientChallenge, 0, targetInfo)
+	if len(resp.SessionBaseKey) != 16 {
+		t.Fatalf("session base key length = %d, want 16", len(resp.SessionBaseKey))
+	}
+	if len(resp.NTChallengeResponse) < 16+28 {
+		t.Fatalf("nt challenge response too short: %d bytes", len(resp.NTChallengeResponse))
+	}
+	temp := resp.NTChallengeResponse[16:]
+	if temp[0] != 0x01 || temp[1] != 0x01 {
+		t.Errorf("temp RespType/HiRespType = %x, want 01 01", temp[0:2])
+	}
+	if !bytes.Equal(temp[16:24], clientChallenge) {
+		t.Errorf("temp client challenge = %x, want %x", temp[16:24], clientChallenge)
+	}
+}
+
+func TestAuthenticateMessageFields(t *testing.T) {
+	ntResp := []byte{0xAA, 0xBB, 0xCC}
+	lmResp := []byte{0x11, 0x22, 0x33, 0x44}
+	sessionKey := []byte{0x55, 0x66, 0x77, 0x88}
+	msg := AuthenticateMessage("alice", "DOMAIN", ntResp, lmResp, sessionKey)
+
+	if mt := binary.LittleEndian.Uint32(msg[8:12]); mt != 3 {
+		t.Fatalf("message type = %d, want 3", mt)
+	}
+
+	gotLM, err := readField(msg, msg[12:20])
+	if err != nil {
+		t.Fatalf("read lm challenge response: %v", err)
+	}
+	if !bytes.Equal(gotLM, lmResp) {
+		t.Errorf("lm challenge response = %x, want %x", gotLM, lmResp)
+	}
+
+	gotResp, err := readField(msg, msg[20:28])
+	if err != nil {
+		t.Fatalf("read nt challenge response: %v", err)
+	}
+	if !bytes.Equal(gotResp, ntResp) {
+		t.Errorf("nt challenge response = %x, want %x", gotResp, ntResp)
+	}
+
+	user, err := readField(msg, msg[36:44])
+	if err != nil {
+		t.Fatalf("read user field: %v", err)
+	}
+	if string(utf16leDecode(user)) != "alice" {
+		t.Errorf("user = %q, want %q", user, "alice")
+	}
+
+	gotSessionKey, err := readField(msg, msg[52:60])
+	if err != nil {
+		t.Fatalf("read encrypted random session key: %v", err)
+	}
+	if !bytes.Equal(gotSessionKey, sessionKey) {
+		t.Errorf("encrypted random session key = %x, want %x", gotSessionKey, sessionKey)
+	}
+}
+
+// utf16leDecode is the test-only inverse of utf16le.
+func utf16leDecode(b []byte) []byte {
+	out := make([]byte, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		out = append(out, byte(binary.LittleEndian.Uint16(b[i:i+2])))
+	}
+	return out
+}
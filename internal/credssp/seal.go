@@ -0,0 +1,135 @@
+package credssp
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/binary"
+	"fmt"
+)
+
+// Signing/sealing key derivation magic constants, MS-NLMP 3.4.5.3.
+var (
+	clientSigningConstant = []byte("session key to client-to-server signing key magic constant\x00")
+	serverSigningConstant = []byte("session key to server-to-client signing key magic constant\x00")
+	clientSealingConstant = []byte("session key to client-to-server sealing key magic constant\x00")
+	serverSealingConstant = []byte("session key to server-to-client sealing key magic constant\x00")
+)
+
+// sessionKeys holds the four keys NTLM message confidentiality/integrity
+// (MS-NLMP 3.4.3, GSS_WrapEx) derives from exportedSessionKey once Extended
+// Session Security is negotiated: one signing and one sealing key per
+// direction, since client->server and server->client each keep an
+// independent RC4 stream and sequence number.
+type sessionKeys struct {
+	clientSigningKey []byte
+	serverSigningKey []byte
+	clientSealingKey []byte
+	serverSealingKey []byte
+}
+
+func deriveSessionKeys(exportedSessionKey []byte) sessionKeys {
+	return sessionKeys{
+		clientSigningKey: md5Sum(exportedSessionKey, clientSigningConstant),
+		serverSigningKey: md5Sum(exportedSessionKey, serverSigningConstant),
+		clientSealingKey: md5Sum(exportedSessionKey, clientSealingConstant),
+		serverSealingKey: md5Sum(exportedSessionKey, serverSealingConstant),
+	}
+}
+
+func md5Sum(parts ...[]byte) []byte {
+	h := md5.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// rc4Once RC4-encrypts plaintext with a fresh cipher keyed by key, the way
+// AUTHENTICATE's EncryptedRandomSessionKey field is produced (MS-NLMP
+// 3.1.5.1.2): a single-shot operation, not part of an ongoing stream.
+func rc4Once(key, plaintext []byte) ([]byte, error) {
+	c, err := rc4.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("credssp: rc4 cipher: %w", err)
+	}
+	out := make([]byte, len(plaintext))
+	c.XORKeyStream(out, plaintext)
+	return out, nil
+}
+
+// sealer wraps one direction's RC4 stream and sequence counter for
+// GSS_WrapEx-style sealing (MS-NLMP 3.4.3). NTLMSSP_NEGOTIATE_KEY_EXCH is
+// always negotiated by this package, so the per-message checksum is itself
+// RC4-encrypted with the same stream as the payload. A sealer may only be
+// used for messages traveling in one direction, in sequence order.
+type sealer struct {
+	cipher     *rc4.Cipher
+	signingKey []byte
+	seqNum     uint32
+}
+
+func newSealer(sealingKey, signingKey []byte) (*sealer, error) {
+	c, err := rc4.NewCipher(sealingKey)
+	if err != nil {
+		return nil, fmt.Errorf("credssp: rc4 cipher: %w", err)
+	}
+	return &sealer{cipher: c, signingKey: signingKey}, nil
+}
+
+// seal encrypts plaintext and returns the NTLMSSP_MESSAGE_SIGNATURE
+// (MS-NLMP 2.2.2.9) alongside it.
+func (s *sealer) seal(plaintext []byte) (sealed, signature []byte) {
+	sealed = make([]byte, len(plaintext))
+	s.cipher.XORKeyStream(sealed, plaintext)
+
+	checksum := s.checksum(plaintext)
+	sealedChecksum := make([]byte, 8)
+	s.cipher.XORKeyStream(sealedChecksum, checksum)
+
+	signature = s.buildSignature(sealedChecksum)
+	s.seqNum++
+	return sealed, signature
+}
+
+// unseal reverses seal: it decrypts sealed and verifies signature against
+// the recovered plaintext, returning an error if the checksum doesn't match
+// a message that was tampered with or misdirected.
+func (s *sealer) unseal(sealed, signature []byte) ([]byte, error) {
+	if len(signature) != 16 {
+		return nil, fmt.Errorf("credssp: signature length = %d, want 16", len(signature))
+	}
+
+	plaintext := make([]byte, len(sealed))
+	s.cipher.XORKeyStream(plaintext, sealed)
+
+	checksum := s.checksum(plaintext)
+	sealedChecksum := make([]byte, 8)
+	s.cipher.XORKeyStream(sealedChecksum, checksum)
+
+	want := s.buildSignature(sealedChecksum)
+	s.seqNum++
+
+	if !hmac.Equal(want, signature) {
+		return nil, fmt.Errorf("credssp: message signature mismatch")
+	}
+	return plaintext, nil
+}
+
+func (s *sealer) checksum(plaintext []byte) []byte {
+	seqBuf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(seqBuf, s.seqNum)
+
+	mac := hmac.New(md5.New, s.signingKey)
+	mac.Write(seqBuf)
+	mac.Write(plaintext)
+	return mac.Sum(nil)[:8]
+}
+
+func (s *sealer) buildSignature(sealedChecksum []byte) []byte {
+	signature := make([]byte, 16)
+	binary.LittleEndian.PutUint32(signature[0:4], 1) // version
+	copy(signature[4:12], sealedChecksum)
+	binary.LittleEndian.PutUint32(signature[12:16], s.seqNum)
+	return signature
+}
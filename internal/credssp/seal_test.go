@@ -0,0 +1,80 @@
+package credssp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSealerRoundTrip(t *testing.T) {
+	keys := deriveSessionKeys([]byte("0123456789abcdef"))
+
+	sender, err := newSealer(keys.clientSealingKey, keys.clientSigningKey)
+	if err != nil {
+		t.Fatalf("newSealer (sender): %v", err)
+	}
+	receiver, err := newSealer(keys.clientSealingKey, keys.clientSigningKey)
+	if err != nil {
+		t.Fatalf("newSealer (receiver): %v", err)
+	}
+
+	plaintext := []byte("the server's public key, for binding")
+	sealed, signature := sender.seal(plaintext)
+
+	got, err := receiver.unseal(sealed, signature)
+	if err != nil {
+		t.Fatalf("unseal: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("unseal = %q, want %q", got, plaintext)
+	}
+}
+
+func TestSealerUnsealRejectsTamperedSignature(t *testing.T) {
+	keys := deriveSessionKeys([]byte("0123456789abcdef"))
+
+	sender, err := newSealer(keys.clientSealingKey, keys.clientSigningKey)
+	if err != nil {
+		t.Fatalf("newSealer (sender): %v", err)
+	}
+	receiver, err := newSealer(keys.clientSealingKey, keys.clientSigningKey)
+	if err != nil {
+		t.Fatalf("newSealer (receiver): %v", err)
+	}
+
+	sealed, signature := sender.seal([]byte("some data"))
+	signature[4] ^= 0xFF
+
+	if _, err := receiver.unseal(sealed, signature); err == nil {
+		t.Fatal("unseal accepted a tampered signature")
+	}
+}
+
+func TestIncrementPublicKey(t *testing.T) {
+	got := incrementPublicKey([]byte{0x00, 0x01, 0xFF})
+	want := []byte{0x00, 0x02, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Errorf("incrementPublicKey = %x, want %x", got, want)
+	}
+
+	// A carry out of the most significant byte must not change the output
+	// length; incrementPublicKey re-fits the result to the input's size.
+	gotOverflow := incrementPublicKey([]byte{0xFF, 0xFF})
+	wantOverflow := []byte{0x00, 0x00}
+	if !bytes.Equal(gotOverflow, wantOverflow) {
+		t.Errorf("incrementPublicKey (overflow) = %x, want %x", gotOverflow, wantOverflow)
+	}
+}
+
+func TestComputeLMv2Response(t *testing.T) {
+	ntowfv2 := NTOWFv2("Password1", "User", "DOMAIN")
+	serverChallenge := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	clientChallenge := []byte{8, 7, 6, 5, 4, 3, 2, 1}
+
+	resp := ComputeLMv2Response(ntowfv2, serverChallenge, clientChallenge)
+	if len(resp) != 24 {
+		t.Fatalf("lm v2 response length = %d, want 24", len(resp))
+	}
+	if !bytes.Equal(resp[16:], clientChallenge) {
+		t.Errorf("lm v2 response client challenge suffix = %x, want %x", resp[16:], clientChallenge)
+	}
+}
@@ -0,0 +1,53 @@
+package credssp
+
+import "encoding/asn1"
+
+// TSRequest is the MS-CSSP 2.2.1 TSRequest structure that carries NTLM
+// tokens and the pubKeyAuth channel-binding value between CredSSP peers.
+// authInfo (delegating TSCredentials) is not implemented, see the package
+// doc comment.
+type TSRequest struct {
+	Version    int            `asn1:"tag:0,explicit"`
+	NegoTokens []negoDataItem `asn1:"tag:1,explicit,optional"`
+	PubKeyAuth []byte         `asn1:"tag:3,explicit,optional"`
+}
+
+// negoDataItem is one element of the NegoData SEQUENCE OF in MS-CSSP 2.2.1.1.
+type negoDataItem struct {
+	NegoToken []byte `asn1:"tag:0,explicit"`
+}
+
+// tsRequestVersion is the CredSSP protocol version this package speaks.
+const tsRequestVersion = 6
+
+// NewTSRequest wraps a single NTLM token (NEGOTIATE or AUTHENTICATE) in a
+// TSRequest ready to Marshal onto the wire.
+func NewTSRequest(ntlmToken []byte) *TSRequest {
+	return &TSRequest{
+		Version:    tsRequestVersion,
+		NegoTokens: []negoDataItem{{NegoToken: ntlmToken}},
+	}
+}
+
+// Marshal encodes the TSRequest to DER.
+func (r *TSRequest) Marshal() ([]byte, error) {
+	return asn1.Marshal(*r)
+}
+
+// Token returns the first negoToken carried by the request, or nil if none
+// is present.
+func (r *TSRequest) Token() []byte {
+	if len(r.NegoTokens) == 0 {
+		return nil
+	}
+	return r.NegoTokens[0].NegoToken
+}
+
+// UnmarshalTSRequest decodes a TSRequest from DER.
+func UnmarshalTSRequest(b []byte) (*TSRequest, error) {
+	req := &TSRequest{}
+	if _, err := asn1.Unmarshal(b, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
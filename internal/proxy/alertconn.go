@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+
+	"github.com/djosix/IronRDP-Proxy-Go/internal/rdcleanpath"
+)
+
+// tlsRecordAlert is the TLS record content type for alert messages, per
+// RFC 8446 section 5.1.
+const tlsRecordAlert = 21
+
+// alertSniffConn wraps a net.Conn and watches plaintext reads for a TLS
+// alert record so the alert description byte can be recovered even when
+// crypto/tls only reports a generic handshake error to the caller. It only
+// needs to look at the first few bytes of a record, so it does not attempt
+// to reassemble alerts split across multiple Read calls.
+type alertSniffConn struct {
+	net.Conn
+	lastAlert *uint8
+}
+
+func newAlertSniffConn(conn net.Conn) *alertSniffConn {
+	return &alertSniffConn{Conn: conn}
+}
+
+func (c *alertSniffConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	// TLS record header is 5 bytes: type(1) version(2) length(2), followed
+	// by the alert body: level(1) description(1).
+	if n >= 7 && p[0] == tlsRecordAlert {
+		desc := p[6]
+		c.lastAlert = &desc
+	}
+	return n, err
+}
+
+// Err wraps err with the sniffed TLS alert, if one was observed, so that
+// rdcleanpath.NewErrResp can recover the alert code.
+func (c *alertSniffConn) Err(err error) error {
+	if err == nil || c.lastAlert == nil {
+		return err
+	}
+	return errors.Join(err, rdcleanpath.TLSAlertError(*c.lastAlert))
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/djosix/IronRDP-Proxy-Go/internal/rdcleanpath"
+)
+
+// Authenticator validates the ProxyAuth token carried by an RDCleanPath
+// request before the proxy dials the destination server. It runs right
+// after the request is decoded, so rejected connections never open a
+// socket to the destination.
+type Authenticator interface {
+	Authenticate(req *rdcleanpath.Pdu) error
+}
+
+// staticTokenAuthenticator accepts a connection if ProxyAuth matches one of
+// a fixed set of tokens, loaded once from a config file at startup.
+type staticTokenAuthenticator struct {
+	tokens map[string]struct{}
+}
+
+// NewStaticTokenAuthenticator builds an Authenticator that accepts any of
+// the given tokens.
+func NewStaticTokenAuthenticator(tokens []string) Authenticator {
+	set := make(map[string]struct{}, len(tokens))
+	for _, t := range tokens {
+		set[t] = struct{}{}
+	}
+	return &staticTokenAuthenticator{tokens: set}
+}
+
+// staticTokenConfig is the on-disk shape read by LoadStaticTokenAuthenticator.
+type staticTokenConfig struct {
+	Tokens []string `json:"tokens"`
+}
+
+// LoadStaticTokenAuthenticator reads a {"tokens": [...]} config file from
+// path and returns an Authenticator backed by it.
+func LoadStaticTokenAuthenticator(path string) (Authenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read auth config: %w", err)
+	}
+	var cfg staticTokenConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse auth config: %w", err)
+	}
+	return NewStaticTokenAuthenticator(cfg.Tokens), nil
+}
+
+func (a *staticTokenAuthenticator) Authenticate(req *rdcleanpath.Pdu) error {
+	if _, ok := a.tokens[req.ProxyAuth]; !ok {
+		return &rdcleanpath.AuthError{Reason: "invalid or missing proxy auth token"}
+	}
+	return nil
+}
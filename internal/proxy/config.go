@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Config bundles the proxy-wide settings that used to be hardcoded: where to
+// expose Prometheus metrics, how hard to rate-limit each client, whether to
+// require ProxyAuth, whether to negotiate NLA with the destination server on
+// the client's behalf, and where to record sessions. It is built once at
+// startup and its derived fields are shared by every connection's Handle
+// call via the With* options returned from Options.
+type Config struct {
+	// MetricsPath is the path the metrics endpoint is registered at on the
+	// caller's http.ServeMux, e.g. "/metrics". Leave empty to skip
+	// registering it.
+	MetricsPath string
+	// RateLimit bounds how fast a single client IP may push bytes in
+	// either direction. A zero value disables rate limiting.
+	RateLimit RateLimitConfig
+	// AuthTokensPath, if set, is loaded as a {"tokens": [...]} file and
+	// every connection's ProxyAuth is checked against it. Leave empty to
+	// accept any connection.
+	AuthTokensPath string
+	// NegotiateWithServer, if true, completes NLA with the destination
+	// server using the connection's ServerAuth field ("domain\\user:pass")
+	// instead of handing raw CredSSP bytes through to the client.
+	NegotiateWithServer bool
+	// RecordingPathTemplate, if set, enables session recording; it must
+	// contain exactly one "%s" verb filled in with a per-session id, e.g.
+	// "/var/recordings/%s.rec". Leave empty to disable recording.
+	RecordingPathTemplate string
+
+	// Metrics, RateLimiter, and Authenticator are populated by Config.Init
+	// and then passed to Handle via Options for every connection.
+	Metrics       *Metrics
+	RateLimiter   *RateLimiter
+	Authenticator Authenticator
+}
+
+// Init creates the Metrics registry (registering it on mux at MetricsPath,
+// if set), the RateLimiter, and the Authenticator (loaded from
+// AuthTokensPath, if set), populating the corresponding fields for callers
+// to thread into Handle via Options.
+func (c *Config) Init(mux *http.ServeMux) error {
+	registry := prometheus.NewRegistry()
+	c.Metrics = NewMetrics(registry)
+
+	if c.MetricsPath != "" {
+		mux.Handle(c.MetricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	}
+
+	if c.RateLimit.BytesPerSecond > 0 {
+		c.RateLimiter = NewRateLimiter(c.RateLimit)
+	}
+
+	if c.AuthTokensPath != "" {
+		authenticator, err := LoadStaticTokenAuthenticator(c.AuthTokensPath)
+		if err != nil {
+			return err
+		}
+		c.Authenticator = authenticator
+	}
+
+	return nil
+}
+
+// Options returns the Handle options this Config implies: metrics always,
+// everything else only if configured.
+func (c *Config) Options(clientIP string) []Option {
+	opts := []Option{WithMetrics(c.Metrics), WithClientIP(clientIP)}
+	if c.RateLimiter != nil {
+		opts = append(opts, WithRateLimiter(c.RateLimiter))
+	}
+	if c.Authenticator != nil {
+		opts = append(opts, WithAuthenticator(c.Authenticator))
+	}
+	if c.NegotiateWithServer {
+		opts = append(opts, WithServerNegotiator(NewCredSSPNegotiator()))
+	}
+	if c.RecordingPathTemplate != "" {
+		opts = append(opts, WithRecording(c.RecordingPathTemplate))
+	}
+	return opts
+}
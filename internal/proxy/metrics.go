@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/djosix/IronRDP-Proxy-Go/internal/record"
+	"github.com/djosix/IronRDP-Proxy-Go/internal/tpkt"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// directionLabel renders a record.Direction as a Prometheus label value.
+func directionLabel(d record.Direction) string {
+	if d == record.ClientToServer {
+		return "client_to_server"
+	}
+	return "server_to_client"
+}
+
+// actionLabel renders a tpkt.Action as a Prometheus label value.
+func actionLabel(a tpkt.Action) string {
+	switch a {
+	case tpkt.ActionFastPath:
+		return "fastpath"
+	case tpkt.ActionX224:
+		return "x224"
+	default:
+		return "unknown"
+	}
+}
+
+// Metrics holds the per-proxy Prometheus collectors tracking Fast-Path
+// flow: PDU and byte counts by direction/action, per-PDU read latency, and
+// the failure counters that used to be invisible once Handle just closed
+// the WebSocket on error.
+type Metrics struct {
+	pdusTotal           *prometheus.CounterVec
+	bytesTotal          *prometheus.CounterVec
+	pduLatencySeconds   *prometheus.HistogramVec
+	malformedFrames     prometheus.Counter
+	tlsAlertsTotal      prometheus.Counter
+	rdcleanpathFailures prometheus.Counter
+}
+
+// NewMetrics creates a Metrics bundle and registers it with reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		pdusTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "ironrdp_proxy",
+			Name:      "pdus_total",
+			Help:      "RDP PDUs relayed, by direction and Fast-Path action.",
+		}, []string{"direction", "action"}),
+		bytesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "ironrdp_proxy",
+			Name:      "bytes_total",
+			Help:      "Bytes relayed, by direction.",
+		}, []string{"direction"}),
+		pduLatencySeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "ironrdp_proxy",
+			Name:      "pdu_read_latency_seconds",
+			Help:      "Time spent reading a single PDU off the wire, by direction.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"direction"}),
+		malformedFrames: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "ironrdp_proxy",
+			Name:      "malformed_frames_total",
+			Help:      "Frames that failed to parse as a PDU.",
+		}),
+		tlsAlertsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "ironrdp_proxy",
+			Name:      "tls_alerts_total",
+			Help:      "TLS alerts observed from destination servers.",
+		}),
+		rdcleanpathFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "ironrdp_proxy",
+			Name:      "rdcleanpath_decode_failures_total",
+			Help:      "RDCleanPath requests that failed to decode.",
+		}),
+	}
+
+	reg.MustRegister(
+		m.pdusTotal,
+		m.bytesTotal,
+		m.pduLatencySeconds,
+		m.malformedFrames,
+		m.tlsAlertsTotal,
+		m.rdcleanpathFailures,
+	)
+	return m
+}
+
+func (m *Metrics) observePdu(direction record.Direction, action tpkt.Action, bytes int, latency time.Duration) {
+	if m == nil {
+		return
+	}
+	dl := directionLabel(direction)
+	m.pdusTotal.WithLabelValues(dl, actionLabel(action)).Inc()
+	m.bytesTotal.WithLabelValues(dl).Add(float64(bytes))
+	m.pduLatencySeconds.WithLabelValues(dl).Observe(latency.Seconds())
+}
+
+func (m *Metrics) observeMalformedFrame() {
+	if m == nil {
+		return
+	}
+	m.malformedFrames.Inc()
+}
+
+func (m *Metrics) observeTlsAlert() {
+	if m == nil {
+		return
+	}
+	m.tlsAlertsTotal.Inc()
+}
+
+func (m *Metrics) observeRdcleanpathFailure() {
+	if m == nil {
+		return
+	}
+	m.rdcleanpathFailures.Inc()
+}
@@ -0,0 +1,53 @@
+package proxy
+
+// Option configures optional behavior of Handle.
+type Option func(*options)
+
+type options struct {
+	authenticator    Authenticator
+	serverNegotiator ServerNegotiator
+	recordingPath    string
+	metrics          *Metrics
+	rateLimiter      *RateLimiter
+	clientIP         string
+}
+
+// WithAuthenticator enforces auth on every connection's ProxyAuth token
+// before the proxy dials the destination server.
+func WithAuthenticator(a Authenticator) Option {
+	return func(o *options) { o.authenticator = a }
+}
+
+// WithServerNegotiator completes NLA with the destination server using the
+// connection's ServerAuth field once the TLS upgrade to the server
+// succeeds, before traffic starts flowing.
+func WithServerNegotiator(n ServerNegotiator) Option {
+	return func(o *options) { o.serverNegotiator = n }
+}
+
+// WithRecording enables session recording to a file at pathTemplate, which
+// must contain exactly one "%s" verb filled in with a per-session id, e.g.
+// "/var/recordings/%s.rec".
+func WithRecording(pathTemplate string) Option {
+	return func(o *options) { o.recordingPath = pathTemplate }
+}
+
+// WithMetrics reports PDU/byte counts, per-PDU read latency, and failure
+// counters to m.
+func WithMetrics(m *Metrics) Option {
+	return func(o *options) { o.metrics = m }
+}
+
+// WithRateLimiter caps how fast clientIP (see WithClientIP) may push bytes
+// in either direction.
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(o *options) { o.rateLimiter = l }
+}
+
+// WithClientIP records the client's IP so the rate limiter can key its
+// token buckets on it. Callers typically derive this from the HTTP request
+// that was upgraded to a WebSocket, since *websocket.Conn has no notion of
+// it.
+func WithClientIP(ip string) Option {
+	return func(o *options) { o.clientIP = ip }
+}
@@ -4,63 +4,90 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
-	"io"
 	"net"
 
 	"github.com/djosix/IronRDP-Proxy-Go/internal/rdcleanpath"
+	"github.com/djosix/IronRDP-Proxy-Go/internal/record"
 	"github.com/djosix/IronRDP-Proxy-Go/internal/tpkt"
 	"github.com/gorilla/websocket"
 	"golang.org/x/sync/errgroup"
 )
 
-func Handle(ctx context.Context, ws *websocket.Conn) error {
+func Handle(ctx context.Context, ws *websocket.Conn, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	rdpClientConn := newWsReadWriteCloser(ws)
 
+	// fail reports err to the client as an RDCleanPath error PDU before the
+	// caller closes the WebSocket, so the browser can tell "host
+	// unreachable" apart from "TLS alert" apart from "bad PDU" instead of
+	// just seeing the socket drop.
+	fail := func(err error) error {
+		if der, merr := rdcleanpath.NewErrResp(err).Marshal(); merr == nil {
+			rdpClientConn.Write(der)
+		}
+		return err
+	}
+
 	// Read RCCleanPath request from client
 	var cleanPathReq *rdcleanpath.Pdu
 	{
 		frame, _, err := tpkt.ReadFrame(rdpClientConn)
 		if err != nil {
-			return fmt.Errorf("read frame: %v", err)
+			return fail(fmt.Errorf("read frame: %v", err))
 		}
 		pdu, err := rdcleanpath.Unmarshal(frame)
 		if err != nil {
-			return fmt.Errorf("decode rdcleanpath pdu: %v", err)
+			o.metrics.observeRdcleanpathFailure()
+			return fail(fmt.Errorf("decode rdcleanpath pdu: %w", err))
 		}
 		cleanPathReq = pdu
 	}
 
+	if o.authenticator != nil {
+		if err := o.authenticator.Authenticate(cleanPathReq); err != nil {
+			return fail(err)
+		}
+	}
+
 	rdpServerConn, err := net.Dial("tcp", cleanPathReq.Destination)
 	if err != nil {
-		return fmt.Errorf("dial server: %v", err)
+		return fail(fmt.Errorf("dial server: %w", err))
 	}
 
 	// Write X224 connection PDU to server
 	if _, err := rdpServerConn.Write(
 		append([]byte(cleanPathReq.PreconnectionBlob), cleanPathReq.X224ConnectionPdu...),
 	); err != nil {
-		return fmt.Errorf("conn write: %v", err)
+		return fail(fmt.Errorf("conn write: %v", err))
 	}
 
 	// Read X224 connection PDU from server
 	x224Resp, _, err := tpkt.ReadFrame(rdpServerConn)
 	if err != nil {
-		return fmt.Errorf("conn read: %v", err)
+		return fail(fmt.Errorf("conn read: %v", err))
 	}
 
 	// Upgrade connection to TLS and collect certificate chain
 	certChain := [][]byte{}
 	{
-		tlsConn := tls.Client(rdpServerConn, &tls.Config{
+		sniffConn := newAlertSniffConn(rdpServerConn)
+		tlsConn := tls.Client(sniffConn, &tls.Config{
 			InsecureSkipVerify: true,
 			MaxVersion:         tls.VersionTLS12, // Works with most Windows
 		})
 		if err := tlsConn.HandshakeContext(ctx); err != nil {
-			return fmt.Errorf("tls server: %v", err)
+			if sniffConn.lastAlert != nil {
+				o.metrics.observeTlsAlert()
+			}
+			return fail(sniffConn.Err(fmt.Errorf("tls server: %w", err)))
 		}
 		peerCertificates := tlsConn.ConnectionState().PeerCertificates
 		if len(peerCertificates) == 0 {
-			return fmt.Errorf("no peer certificates found")
+			return fail(fmt.Errorf("no peer certificates found"))
 		}
 		for _, cert := range peerCertificates {
 			certChain = append(certChain, cert.Raw)
@@ -69,32 +96,48 @@ func Handle(ctx context.Context, ws *websocket.Conn) error {
 		rdpServerConn = tlsConn
 	}
 
+	if o.serverNegotiator != nil {
+		if err := o.serverNegotiator.Negotiate(rdpServerConn, cleanPathReq); err != nil {
+			return fail(err)
+		}
+	}
+
 	// Write RCCleanPath response to client
 	{
 		cleanPathResp, err := rdcleanpath.NewResp(rdpServerConn.RemoteAddr().String(), x224Resp, certChain)
 		if err != nil {
-			return fmt.Errorf("rdcleanpath new resp: %v", err)
+			return fail(fmt.Errorf("rdcleanpath new resp: %v", err))
 		}
 
 		cleanPathRespDer, err := cleanPathResp.Marshal()
 		if err != nil {
-			return fmt.Errorf("rdcleanpath marshal: %v", err)
+			return fail(fmt.Errorf("rdcleanpath marshal: %v", err))
 		}
 
 		if _, err := rdpClientConn.Write(cleanPathRespDer); err != nil {
-			return fmt.Errorf("write message: %v", err)
+			return fail(fmt.Errorf("write message: %v", err))
 		}
 	}
 
+	var recorder *syncRecorder
+	if o.recordingPath != "" {
+		rw, closeFile, err := openRecording(o.recordingPath, cleanPathReq.Destination)
+		if err != nil {
+			return fail(err)
+		}
+		defer closeFile()
+		recorder = rw
+	}
+
+	pump := NewPump(o.metrics, recorder, o.rateLimiter)
+
 	// Handle bidirectional communication
 	group, ctx := errgroup.WithContext(ctx)
 	group.Go(func() error {
-		_, err := io.Copy(rdpServerConn, rdpClientConn)
-		return err
+		return pump.Copy(ctx, rdpServerConn, rdpClientConn, record.ClientToServer, o.clientIP)
 	})
 	group.Go(func() error {
-		_, err := io.Copy(rdpClientConn, rdpServerConn)
-		return err
+		return pump.Copy(ctx, rdpClientConn, rdpServerConn, record.ServerToClient, o.clientIP)
 	})
 	group.Go(func() error {
 		<-ctx.Done()
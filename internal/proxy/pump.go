@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/djosix/IronRDP-Proxy-Go/internal/record"
+	"github.com/djosix/IronRDP-Proxy-Go/internal/tpkt"
+)
+
+// Pump relays one direction of a proxied session frame-by-frame using
+// tpkt.Scanner, replacing a raw io.Copy so that every PDU can be counted,
+// optionally recorded, and optionally rate-limited. metrics, recorder, and
+// limiter are all nil-safe: a Pump built with none of them behaves like
+// io.Copy plus frame parsing.
+type Pump struct {
+	metrics  *Metrics
+	recorder *syncRecorder
+	limiter  *RateLimiter
+}
+
+// NewPump builds a Pump from whichever of metrics, recorder, and limiter
+// the caller wants enabled; any of them may be nil.
+func NewPump(metrics *Metrics, recorder *syncRecorder, limiter *RateLimiter) *Pump {
+	return &Pump{metrics: metrics, recorder: recorder, limiter: limiter}
+}
+
+// Copy relays frames from src to dst until src is exhausted or an error
+// occurs. clientIP keys the rate limiter, if one is configured. Each frame
+// is written to dst in a single Write call, since tpkt.Scanner already
+// hands back header and payload as one contiguous slice.
+func (p *Pump) Copy(ctx context.Context, dst io.Writer, src io.Reader, direction record.Direction, clientIP string) error {
+	scanner := tpkt.NewScanner(src)
+
+	// pacingBytes lags one frame behind: a limiter reservation is paid for
+	// the previous frame's size before the next frame is read, so an empty
+	// bucket stalls the next read off src instead of frames being dropped
+	// after the fact.
+	pacingBytes := 0
+
+	for {
+		if p.limiter != nil && pacingBytes > 0 {
+			if err := p.limiter.WaitN(ctx, clientIP, direction, pacingBytes); err != nil {
+				return fmt.Errorf("rate limit: %w", err)
+			}
+		}
+
+		start := time.Now()
+		frame, action, err := scanner.Next()
+		latency := time.Since(start)
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			p.metrics.observeMalformedFrame()
+			return err
+		}
+
+		if _, err := dst.Write(frame); err != nil {
+			scanner.Release(frame)
+			return err
+		}
+
+		p.metrics.observePdu(direction, action, len(frame), latency)
+
+		if p.recorder != nil {
+			rawFrame := append([]byte{}, frame...)
+			if err := p.recorder.WriteFrame(direction, action, rawFrame); err != nil {
+				scanner.Release(frame)
+				return fmt.Errorf("recording: write frame: %w", err)
+			}
+		}
+
+		scanner.Release(frame)
+		pacingBytes = len(frame)
+	}
+}
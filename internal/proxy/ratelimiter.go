@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/djosix/IronRDP-Proxy-Go/internal/record"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitConfig bounds how fast a single client IP may push bytes in
+// either direction through the proxy.
+type RateLimitConfig struct {
+	// BytesPerSecond is the sustained rate each (client IP, direction) pair
+	// is allowed.
+	BytesPerSecond float64
+	// Burst is the maximum number of bytes that can be admitted instantly
+	// before BytesPerSecond starts throttling.
+	Burst int
+}
+
+// rateLimiterKey identifies one token bucket: a client IP and the
+// direction of traffic it is pushing.
+type rateLimiterKey struct {
+	clientIP  string
+	direction record.Direction
+}
+
+// RateLimiter hands out a token-bucket limiter per (client IP, direction)
+// pair, so one noisy client cannot starve bandwidth from others sharing
+// the proxy. Buckets are created lazily and never evicted; long-lived
+// proxies with many distinct client IPs should size BytesPerSecond/Burst
+// with that in mind.
+type RateLimiter struct {
+	cfg RateLimitConfig
+
+	mu       sync.Mutex
+	limiters map[rateLimiterKey]*rate.Limiter
+}
+
+// NewRateLimiter creates a RateLimiter enforcing cfg on every (client IP,
+// direction) pair it is asked about.
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		cfg:      cfg,
+		limiters: make(map[rateLimiterKey]*rate.Limiter),
+	}
+}
+
+// WaitN blocks until n bytes worth of tokens are available for clientIP in
+// direction, or ctx is done. Callers are expected to call this before
+// reading more bytes off the client connection, so an empty bucket
+// naturally backpressures the client rather than the proxy dropping data.
+//
+// n is clamped to cfg.Burst: rate.Limiter.WaitN rejects any n that exceeds
+// the bucket's burst size outright, and a single PDU can legitimately be
+// larger than a conservatively-sized burst (e.g. a RateLimitConfig tuned
+// near the ~64 KB maximum X.224 frame size). Without the clamp, the first
+// oversized frame would fail the connection instead of merely pacing it.
+func (l *RateLimiter) WaitN(ctx context.Context, clientIP string, direction record.Direction, n int) error {
+	if l.cfg.Burst > 0 && n > l.cfg.Burst {
+		n = l.cfg.Burst
+	}
+	return l.limiterFor(clientIP, direction).WaitN(ctx, n)
+}
+
+func (l *RateLimiter) limiterFor(clientIP string, direction record.Direction) *rate.Limiter {
+	key := rateLimiterKey{clientIP: clientIP, direction: direction}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lim, ok := l.limiters[key]
+	if !ok {
+		lim = rate.NewLimiter(rate.Limit(l.cfg.BytesPerSecond), l.cfg.Burst)
+		l.limiters[key] = lim
+	}
+	return lim
+}
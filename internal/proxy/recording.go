@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/djosix/IronRDP-Proxy-Go/internal/record"
+	"github.com/djosix/IronRDP-Proxy-Go/internal/tpkt"
+)
+
+// syncRecorder serializes writes to a record.Writer, which on its own is
+// not safe for concurrent use. Handle taps both the client->server and
+// server->client copy goroutines into the same recording, so they share
+// one of these.
+type syncRecorder struct {
+	mu  sync.Mutex
+	rec *record.Writer
+}
+
+func (s *syncRecorder) WriteFrame(direction record.Direction, action tpkt.Action, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rec.WriteFrame(direction, action, payload)
+}
+
+// newSessionID generates an identifier for templating a recording path,
+// e.g. "/var/recordings/%s.rec" -> "/var/recordings/3f9a2b1c5d7e8f01.rec".
+func newSessionID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// openRecording resolves pathTemplate's single "%s" verb against a fresh
+// session id and creates the recording file.
+func openRecording(pathTemplate, serverAddr string) (*syncRecorder, func() error, error) {
+	sessionID, err := newSessionID()
+	if err != nil {
+		return nil, nil, fmt.Errorf("recording: session id: %w", err)
+	}
+	path := fmt.Sprintf(pathTemplate, sessionID)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("recording: create %s: %w", path, err)
+	}
+	rw, err := record.NewWriter(f, serverAddr)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("recording: write header: %w", err)
+	}
+	return &syncRecorder{rec: rw}, f.Close, nil
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/djosix/IronRDP-Proxy-Go/internal/credssp"
+	"github.com/djosix/IronRDP-Proxy-Go/internal/rdcleanpath"
+)
+
+// ServerNegotiator completes NLA with the destination RDP server on behalf
+// of a client that only speaks RDCleanPath. It runs after the TLS upgrade
+// to the server and before the connection is handed to the copy loop.
+type ServerNegotiator interface {
+	Negotiate(conn io.ReadWriter, req *rdcleanpath.Pdu) error
+}
+
+// credsspNegotiator is the ServerNegotiator backing CredSSP/NTLMv2
+// pass-through: it parses "domain\\user:password" out of ServerAuth and
+// runs it through credssp.Negotiator.
+type credsspNegotiator struct{}
+
+// NewCredSSPNegotiator returns a ServerNegotiator that negotiates NTLMv2
+// over CredSSP using credentials carried in the request's ServerAuth field,
+// formatted as "domain\\user:password".
+func NewCredSSPNegotiator() ServerNegotiator {
+	return &credsspNegotiator{}
+}
+
+func (n *credsspNegotiator) Negotiate(conn io.ReadWriter, req *rdcleanpath.Pdu) error {
+	domain, user, password, err := parseServerAuth(req.ServerAuth)
+	if err != nil {
+		return &rdcleanpath.AuthError{Reason: fmt.Sprintf("server auth: %v", err)}
+	}
+
+	serverPublicKey, err := serverPublicKey(conn)
+	if err != nil {
+		return &rdcleanpath.AuthError{Reason: fmt.Sprintf("credssp: %v", err)}
+	}
+
+	negotiator := &credssp.Negotiator{Domain: domain, User: user, Password: password, ServerPublicKey: serverPublicKey}
+	if err := negotiator.Negotiate(conn); err != nil {
+		return &rdcleanpath.AuthError{Reason: fmt.Sprintf("credssp negotiation failed: %v", err)}
+	}
+	return nil
+}
+
+// serverPublicKey extracts the DER-encoded SubjectPublicKeyInfo of conn's
+// peer certificate, which credssp.Negotiator binds the NTLM session to via
+// pubKeyAuth. conn is expected to be the *tls.Conn the proxy already
+// upgraded its connection to the RDP server to.
+func serverPublicKey(conn io.ReadWriter) ([]byte, error) {
+	tlsConn, ok := conn.(interface{ ConnectionState() tls.ConnectionState })
+	if !ok {
+		return nil, fmt.Errorf("not a TLS connection")
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no peer certificate")
+	}
+	return certs[0].RawSubjectPublicKeyInfo, nil
+}
+
+// parseServerAuth splits a "domain\\user:password" ServerAuth value.
+func parseServerAuth(serverAuth string) (domain, user, password string, err error) {
+	domainUser, password, ok := strings.Cut(serverAuth, ":")
+	if !ok {
+		return "", "", "", fmt.Errorf(`expected "domain\\user:password"`)
+	}
+	domain, user, ok = strings.Cut(domainUser, `\`)
+	if !ok {
+		// No domain separator: treat the whole thing as the username.
+		return "", domainUser, password, nil
+	}
+	return domain, user, password, nil
+}
@@ -0,0 +1,95 @@
+package rdcleanpath
+
+import (
+	"crypto/tls"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// Error codes carried in Err.ErrorCode. These are proxy-local categories,
+// not part of the RDCleanPath wire spec, so the browser client only needs
+// to tell the broad failure classes apart.
+const (
+	ErrCodeGeneral      = 1 // dial/connect failures, anything uncategorized
+	ErrCodeTlsHandshake = 2 // the server's reply was not a valid TLS record (tls.RecordHeaderError)
+	ErrCodeTlsAlert     = 3 // the server sent a TLS alert
+	ErrCodePduDecode    = 4 // the client's RDCleanPath PDU failed to decode
+	ErrCodeAuth         = 5 // ProxyAuth/ServerAuth was rejected
+)
+
+// AuthError is returned by a proxy.Authenticator (or server negotiator) to
+// reject a connection, so NewErrResp can report ErrCodeAuth instead of
+// falling back to the generic code.
+type AuthError struct {
+	Reason string
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("auth: %s", e.Reason)
+}
+
+// TLSAlertError reports a raw TLS alert description byte observed on the
+// wire. It exists because crypto/tls does not always surface the alert
+// value to the handshake caller; code that sniffs alert records off the
+// connection (see proxy.newAlertSniffConn) wraps the handshake error with
+// this type so NewErrResp can recover the alert code.
+type TLSAlertError uint8
+
+func (e TLSAlertError) Error() string {
+	return fmt.Sprintf("tls: alert received: %d", uint8(e))
+}
+
+// NewErrResp builds an error response PDU describing err, choosing the most
+// specific ErrorCode it can and filling in WsaLastError or TlsAlertCode when
+// the underlying error carries that information. HttpStatusCode is never set.
+func NewErrResp(err error) *Pdu {
+	rdErr := Err{ErrorCode: ErrCodeGeneral}
+
+	var alertErr TLSAlertError
+	var tlsAlertErr tls.AlertError
+	var tlsRecErr tls.RecordHeaderError
+	var asn1Struct asn1.StructuralError
+	var asn1Syntax asn1.SyntaxError
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var authErr *AuthError
+
+	switch {
+	case errors.As(err, &authErr):
+		rdErr.ErrorCode = ErrCodeAuth
+	case errors.As(err, &alertErr):
+		rdErr.ErrorCode = ErrCodeTlsAlert
+		rdErr.TlsAlertCode = uint8(alertErr)
+	case errors.As(err, &tlsAlertErr):
+		rdErr.ErrorCode = ErrCodeTlsAlert
+		rdErr.TlsAlertCode = uint8(tlsAlertErr)
+	case errors.As(err, &tlsRecErr):
+		rdErr.ErrorCode = ErrCodeTlsHandshake
+	case errors.As(err, &asn1Struct), errors.As(err, &asn1Syntax):
+		rdErr.ErrorCode = ErrCodePduDecode
+	case errors.As(err, &dnsErr):
+		rdErr.ErrorCode = ErrCodeGeneral
+	case errors.As(err, &opErr):
+		rdErr.ErrorCode = ErrCodeGeneral
+		if errno, ok := errnoOf(opErr); ok {
+			rdErr.WsaLastError = int(errno)
+		}
+	}
+
+	return &Pdu{
+		Version: 3389 + 1,
+		Error:   rdErr,
+	}
+}
+
+// errnoOf unwraps the syscall.Errno carried by a net.OpError, if any.
+func errnoOf(opErr *net.OpError) (syscall.Errno, bool) {
+	var errno syscall.Errno
+	if errors.As(opErr.Err, &errno) {
+		return errno, true
+	}
+	return 0, false
+}
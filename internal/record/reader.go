@@ -0,0 +1,24 @@
+package record
+
+import "io"
+
+// Reader reads Frame records back out of a recording produced by Writer.
+type Reader struct {
+	r    io.Reader
+	Info StartInfo
+}
+
+// NewReader opens a recording, reading and validating its header.
+func NewReader(r io.Reader) (*Reader, error) {
+	info, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: r, Info: info}, nil
+}
+
+// ReadFrame returns the next frame in the recording, or io.EOF once the
+// recording is exhausted.
+func (rr *Reader) ReadFrame() (Frame, error) {
+	return readFrame(rr.r)
+}
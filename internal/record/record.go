@@ -0,0 +1,136 @@
+// Package record implements a pcap-like file format for capturing both
+// directions of a proxied RDP session and replaying them later, so a
+// session can be re-rendered offline with the same front-end the live
+// proxy feeds.
+package record
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/djosix/IronRDP-Proxy-Go/internal/tpkt"
+)
+
+// magic identifies a recording file.
+var magic = [4]byte{'R', 'D', 'P', 'R'}
+
+// FormatVersion is the current on-disk format version.
+const FormatVersion uint16 = 1
+
+// headerSize is the size of the fixed-width file header, not counting the
+// variable-length server address that follows it.
+const headerSize = 16
+
+// Direction identifies which side of the proxy a frame travelled.
+type Direction uint8
+
+const (
+	// ClientToServer is a frame sent by the browser client to the RDP server.
+	ClientToServer Direction = iota
+	// ServerToClient is a frame sent by the RDP server to the browser client.
+	ServerToClient
+)
+
+// recordHeaderSize is the size of the fixed-width portion of each frame
+// record, not counting its payload.
+const recordHeaderSize = 8 + 1 + 1 + 4
+
+// Frame is one captured PDU. Payload holds the complete wire frame
+// (header and payload as tpkt.ReadFrame would return them combined), so a
+// replay can re-emit it byte-for-byte.
+type Frame struct {
+	TimestampNs uint64
+	Direction   Direction
+	Action      tpkt.Action
+	Payload     []byte
+}
+
+// StartInfo is the file-level metadata read from a recording's header.
+type StartInfo struct {
+	Version        uint16
+	StartTimestamp uint64
+	ServerAddr     string
+}
+
+var errBadMagic = errors.New("record: not a recording file")
+
+// writeHeader writes the 16-byte fixed header plus the server address.
+func writeHeader(w io.Writer, startTimestampNs uint64, serverAddr string) error {
+	addr := []byte(serverAddr)
+	header := make([]byte, headerSize)
+	copy(header[0:4], magic[:])
+	binary.BigEndian.PutUint16(header[4:6], FormatVersion)
+	binary.BigEndian.PutUint64(header[6:14], startTimestampNs)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(addr)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(addr)
+	return err
+}
+
+// readHeader reads and validates the file header.
+func readHeader(r io.Reader) (StartInfo, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return StartInfo{}, err
+	}
+	if [4]byte(header[0:4]) != magic {
+		return StartInfo{}, errBadMagic
+	}
+	info := StartInfo{
+		Version:        binary.BigEndian.Uint16(header[4:6]),
+		StartTimestamp: binary.BigEndian.Uint64(header[6:14]),
+	}
+	addrLen := binary.BigEndian.Uint16(header[14:16])
+	if addrLen > 0 {
+		addr := make([]byte, addrLen)
+		if _, err := io.ReadFull(r, addr); err != nil {
+			return StartInfo{}, err
+		}
+		info.ServerAddr = string(addr)
+	}
+	return info, nil
+}
+
+// writeFrame writes one frame record: timestamp_ns, direction, action,
+// length, payload.
+func writeFrame(w io.Writer, f Frame) error {
+	header := make([]byte, recordHeaderSize)
+	binary.BigEndian.PutUint64(header[0:8], f.TimestampNs)
+	header[8] = byte(f.Direction)
+	header[9] = byte(f.Action)
+	binary.BigEndian.PutUint32(header[10:14], uint32(len(f.Payload)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(f.Payload)
+	return err
+}
+
+// readFrame reads one frame record, or returns io.EOF once the stream is
+// exhausted cleanly between records.
+func readFrame(r io.Reader) (Frame, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Frame{}, fmt.Errorf("record: truncated frame header: %w", err)
+		}
+		return Frame{}, err
+	}
+	f := Frame{
+		TimestampNs: binary.BigEndian.Uint64(header[0:8]),
+		Direction:   Direction(header[8]),
+		Action:      tpkt.Action(header[9]),
+	}
+	length := binary.BigEndian.Uint32(header[10:14])
+	if length > 0 {
+		f.Payload = make([]byte, length)
+		if _, err := io.ReadFull(r, f.Payload); err != nil {
+			return Frame{}, fmt.Errorf("record: truncated frame payload: %w", err)
+		}
+	}
+	return f, nil
+}
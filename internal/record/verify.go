@@ -0,0 +1,46 @@
+package record
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/djosix/IronRDP-Proxy-Go/internal/tpkt"
+)
+
+// Verify walks every frame in a recording and checks that its payload
+// parses cleanly as a PDU whose size and action match what was recorded,
+// so a corrupt or truncated recording fails fast instead of misbehaving
+// deep into a replay.
+func Verify(r io.Reader) (frameCount int, err error) {
+	rr, err := NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("record: open: %w", err)
+	}
+
+	for {
+		frame, err := rr.ReadFrame()
+		if err == io.EOF {
+			return frameCount, nil
+		}
+		if err != nil {
+			return frameCount, fmt.Errorf("record: frame %d: %w", frameCount, err)
+		}
+
+		info, err := tpkt.FindPduSize(frame.Payload)
+		if err != nil {
+			return frameCount, fmt.Errorf("record: frame %d: %w", frameCount, err)
+		}
+		if info.Action != frame.Action {
+			return frameCount, fmt.Errorf("record: frame %d: action %v does not match recorded action %v", frameCount, info.Action, frame.Action)
+		}
+		size, _, err := tpkt.SplitFunc(frame.Payload, true)
+		if err != nil {
+			return frameCount, fmt.Errorf("record: frame %d: %w", frameCount, err)
+		}
+		if size != len(frame.Payload) {
+			return frameCount, fmt.Errorf("record: frame %d: PDU size %d does not match recorded length %d", frameCount, size, len(frame.Payload))
+		}
+
+		frameCount++
+	}
+}
@@ -0,0 +1,41 @@
+package record
+
+import (
+	"io"
+	"time"
+
+	"github.com/djosix/IronRDP-Proxy-Go/internal/tpkt"
+)
+
+// Writer appends Frame records to an underlying file, stamping each one
+// with a nanosecond timestamp relative to when the Writer was created.
+//
+// Writer is not safe for concurrent use; callers recording both directions
+// of a session should serialize their writes (e.g. through a mutex or a
+// single goroutine fed by a channel).
+type Writer struct {
+	w     io.Writer
+	start time.Time
+}
+
+// NewWriter creates a new recording at w, writing the file header
+// immediately so a verifier can open the file while it is still being
+// written.
+func NewWriter(w io.Writer, serverAddr string) (*Writer, error) {
+	start := time.Now()
+	if err := writeHeader(w, uint64(start.UnixNano()), serverAddr); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, start: start}, nil
+}
+
+// WriteFrame appends a frame, stamping it with the time elapsed since the
+// recording started.
+func (rw *Writer) WriteFrame(direction Direction, action tpkt.Action, payload []byte) error {
+	return writeFrame(rw.w, Frame{
+		TimestampNs: uint64(time.Since(rw.start).Nanoseconds()),
+		Direction:   direction,
+		Action:      action,
+		Payload:     payload,
+	})
+}
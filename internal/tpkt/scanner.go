@@ -0,0 +1,177 @@
+package tpkt
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+)
+
+// bufPool hands out byte slices sized to hold a single PDU header plus
+// payload. Buffers are grown with append and returned to the pool once the
+// caller is done with the frame they back.
+var bufPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 4096)
+		return &buf
+	},
+}
+
+// Scanner reads a stream of RDP frames out of a buffered reader without
+// allocating per frame. Each call to Next reuses a buffer drawn from an
+// internal sync.Pool, and hands back the header and payload as a single
+// contiguous slice so callers can write a frame out in one Write call; the
+// caller must call Release once it is done reading the returned frame.
+//
+// Scanner is not safe for concurrent use.
+type Scanner struct {
+	r *bufio.Reader
+}
+
+// NewScanner wraps r in a Scanner. If r is not already a *bufio.Reader it is
+// wrapped in one.
+func NewScanner(r io.Reader) *Scanner {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Scanner{r: br}
+}
+
+// Next reads the next complete frame (header and payload, concatenated)
+// from the underlying reader. The returned slice is a view into a pooled
+// buffer that remains valid until Release is called with it. On error,
+// frame is nil and the pooled buffer, if any, has already been released.
+func (s *Scanner) Next() (frame []byte, action Action, err error) {
+	bufPtr := bufPool.Get().(*[]byte)
+	buf := (*bufPtr)[:0]
+
+	headerLen, err := s.peekHeaderLen()
+	if err != nil {
+		bufPool.Put(bufPtr)
+		return nil, ActionUnknown, err
+	}
+
+	buf, err = s.readAppend(buf, headerLen)
+	if err != nil {
+		bufPool.Put(bufPtr)
+		return nil, ActionUnknown, err
+	}
+
+	info, err := FindPduSize(buf)
+	if err != nil {
+		bufPool.Put(bufPtr)
+		return nil, ActionUnknown, err
+	}
+
+	payloadLen := info.Length
+	if info.Action == ActionX224 {
+		payloadLen -= TPKTHeaderSize
+	}
+	if payloadLen < 0 {
+		bufPool.Put(bufPtr)
+		return nil, ActionUnknown, errors.New("tpkt: negative payload length")
+	}
+
+	buf, err = s.readAppend(buf, payloadLen)
+	if err != nil {
+		bufPool.Put(bufPtr)
+		return nil, ActionUnknown, err
+	}
+
+	*bufPtr = buf
+	return buf, info.Action, nil
+}
+
+// Release returns a buffer previously backing a frame returned by Next to
+// the pool. Passing any other slice is safe but wastes the reuse
+// opportunity.
+func (s *Scanner) Release(buf []byte) {
+	bufPool.Put(&buf)
+}
+
+// peekHeaderLen determines how many bytes of header are needed to compute
+// the frame size, without consuming them from the reader.
+func (s *Scanner) peekHeaderLen() (int, error) {
+	for n := MinHeaderSize; n <= TPKTHeaderSize; n++ {
+		peek, err := s.r.Peek(n)
+		if err != nil {
+			if err == io.EOF {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if _, err := FindPduSize(peek); err != ErrInsufficientData {
+			return n, err
+		}
+	}
+	return 0, ErrInsufficientData
+}
+
+// headerSize is the slice-based counterpart of peekHeaderLen, used by
+// SplitFunc where the data is already in memory.
+func headerSize(data []byte) (int, error) {
+	for n := MinHeaderSize; n <= TPKTHeaderSize; n++ {
+		if len(data) < n {
+			return 0, ErrInsufficientData
+		}
+		if _, err := FindPduSize(data[:n]); err != ErrInsufficientData {
+			return n, err
+		}
+	}
+	return 0, ErrInsufficientData
+}
+
+// readAppend appends exactly n freshly-read bytes to buf.
+func (s *Scanner) readAppend(buf []byte, n int) ([]byte, error) {
+	if n == 0 {
+		return buf, nil
+	}
+	start := len(buf)
+	buf = append(buf, make([]byte, n)...)
+	if _, err := io.ReadFull(s.r, buf[start:]); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// SplitFunc is a bufio.SplitFunc that recognizes RDP frame boundaries,
+// letting callers drive parsing with a standard bufio.Scanner instead of
+// tpkt.Scanner. Unlike Scanner, each token is copied out by bufio.Scanner's
+// own buffer management, so it does not share the pooled-buffer behavior.
+func SplitFunc(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	headerLen, err := headerSize(data)
+	if err == ErrInsufficientData {
+		if atEOF && len(data) > 0 {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	info, err := FindPduSize(data[:headerLen])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// FindPduSize reports the payload length for Fast-Path frames but the
+	// total (header-inclusive) length for X.224/TPKT frames; normalize to a
+	// total frame length here.
+	total := info.Length
+	if info.Action == ActionFastPath {
+		total = headerLen + info.Length
+	}
+
+	if len(data) < total {
+		if atEOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	return total, data[:total], nil
+}
@@ -0,0 +1,200 @@
+package tpkt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// fastPathFrame builds a Fast-Path frame with a 7-bit length (two-byte
+// header) when payload fits in 7 bits, or a 15-bit length (three-byte
+// header) otherwise.
+func fastPathFrame(payload []byte) []byte {
+	if len(payload) < 0x80 {
+		return append([]byte{0x00, byte(len(payload))}, payload...)
+	}
+	a := byte(0x80 | (len(payload) >> 8))
+	b := byte(len(payload))
+	return append([]byte{0x00, a, b}, payload...)
+}
+
+// x224Frame builds a TPKT/X.224 frame whose 2-byte length field covers the
+// header too, unlike a Fast-Path frame's length field.
+func x224Frame(payload []byte) []byte {
+	total := TPKTHeaderSize + len(payload)
+	frame := []byte{0x03, 0x00, byte(total >> 8), byte(total)}
+	return append(frame, payload...)
+}
+
+func TestScannerNextFastPathShortLength(t *testing.T) {
+	payload := bytes.Repeat([]byte{0xAB}, 10)
+	want := fastPathFrame(payload)
+
+	s := NewScanner(bytes.NewReader(want))
+	got, action, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if action != ActionFastPath {
+		t.Fatalf("action = %v, want ActionFastPath", action)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("frame = %x, want %x", got, want)
+	}
+	s.Release(got)
+}
+
+func TestScannerNextFastPathLongLength(t *testing.T) {
+	payload := bytes.Repeat([]byte{0xCD}, 200)
+	want := fastPathFrame(payload)
+
+	s := NewScanner(bytes.NewReader(want))
+	got, action, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if action != ActionFastPath {
+		t.Fatalf("action = %v, want ActionFastPath", action)
+	}
+	// 3-byte header (0x00, high-bit-set length byte, low length byte).
+	if got[1]&0x80 == 0 {
+		t.Fatalf("header length byte %#x does not have the 15-bit-length high bit set", got[1])
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("frame length = %d, want %d", len(got), len(want))
+	}
+	s.Release(got)
+}
+
+func TestScannerNextX224(t *testing.T) {
+	payload := []byte{0x01, 0x02, 0x03}
+	want := x224Frame(payload)
+
+	s := NewScanner(bytes.NewReader(want))
+	got, action, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if action != ActionX224 {
+		t.Fatalf("action = %v, want ActionX224", action)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("frame = %x, want %x", got, want)
+	}
+	s.Release(got)
+}
+
+// TestScannerNextMultipleFrames checks that consecutive frames of different
+// kinds are scanned off the same stream in order, with no bytes dropped or
+// duplicated at the boundary between them.
+func TestScannerNextMultipleFrames(t *testing.T) {
+	fp := fastPathFrame([]byte{0x11, 0x22})
+	x224 := x224Frame([]byte{0x33, 0x44, 0x55})
+
+	var stream bytes.Buffer
+	stream.Write(fp)
+	stream.Write(x224)
+
+	s := NewScanner(&stream)
+
+	frame1, action1, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next (frame 1): %v", err)
+	}
+	if action1 != ActionFastPath || !bytes.Equal(frame1, fp) {
+		t.Fatalf("frame 1 = action %v frame %x, want ActionFastPath %x", action1, frame1, fp)
+	}
+	s.Release(frame1)
+
+	frame2, action2, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next (frame 2): %v", err)
+	}
+	if action2 != ActionX224 || !bytes.Equal(frame2, x224) {
+		t.Fatalf("frame 2 = action %v frame %x, want ActionX224 %x", action2, frame2, x224)
+	}
+	s.Release(frame2)
+
+	if _, _, err := s.Next(); err != io.ErrUnexpectedEOF && err != io.EOF {
+		t.Fatalf("Next at end of stream: %v, want EOF", err)
+	}
+}
+
+// TestScannerBufferReuseAcrossRelease checks that Next/Release can be
+// called repeatedly on the same Scanner without the returned slice from an
+// earlier, already-released frame corrupting a later one.
+func TestScannerBufferReuseAcrossRelease(t *testing.T) {
+	frame := fastPathFrame([]byte{0xEE, 0xFF})
+
+	s := NewScanner(bytes.NewReader(append(append([]byte{}, frame...), frame...)))
+
+	frame1, _, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next (first): %v", err)
+	}
+	if !bytes.Equal(frame1, frame) {
+		t.Fatalf("frame = %x, want %x", frame1, frame)
+	}
+	s.Release(frame1)
+
+	frame2, _, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next (second): %v", err)
+	}
+	if !bytes.Equal(frame2, frame) {
+		t.Fatalf("frame = %x, want %x", frame2, frame)
+	}
+	s.Release(frame2)
+}
+
+func TestScannerNextTruncatedStream(t *testing.T) {
+	frame := fastPathFrame([]byte{0x01, 0x02, 0x03})
+	truncated := frame[:len(frame)-1]
+
+	s := NewScanner(bytes.NewReader(truncated))
+	_, _, err := s.Next()
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("Next on truncated stream: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestSplitFunc(t *testing.T) {
+	fp := fastPathFrame([]byte{0xAA, 0xBB})
+	x224 := x224Frame([]byte{0xCC})
+
+	data := append(append([]byte{}, fp...), x224...)
+
+	advance, token, err := SplitFunc(data, false)
+	if err != nil {
+		t.Fatalf("SplitFunc (frame 1): %v", err)
+	}
+	if advance != len(fp) {
+		t.Fatalf("advance = %d, want %d", advance, len(fp))
+	}
+	if !bytes.Equal(token, fp) {
+		t.Fatalf("token = %x, want %x", token, fp)
+	}
+
+	advance2, token2, err := SplitFunc(data[advance:], false)
+	if err != nil {
+		t.Fatalf("SplitFunc (frame 2): %v", err)
+	}
+	if advance2 != len(x224) {
+		t.Fatalf("advance = %d, want %d", advance2, len(x224))
+	}
+	if !bytes.Equal(token2, x224) {
+		t.Fatalf("token = %x, want %x", token2, x224)
+	}
+
+	// A partial frame with atEOF=false should ask for more data rather than
+	// erroring or returning a short token.
+	advance3, token3, err := SplitFunc(fp[:len(fp)-1], false)
+	if err != nil || advance3 != 0 || token3 != nil {
+		t.Fatalf("SplitFunc (partial, not atEOF) = (%d, %x, %v), want (0, nil, nil)", advance3, token3, err)
+	}
+
+	// The same partial frame with atEOF=true is a truncation error.
+	if _, _, err := SplitFunc(fp[:len(fp)-1], true); err != io.ErrUnexpectedEOF {
+		t.Fatalf("SplitFunc (partial, atEOF) err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
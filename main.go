@@ -2,22 +2,50 @@ package main
 
 import (
 	"log"
+	"net"
 	"net/http"
 
 	"github.com/djosix/IronRDP-Proxy-Go/internal/proxy"
 	"github.com/gorilla/websocket"
 )
 
+// clientIP strips the ephemeral port off an http.Request.RemoteAddr so the
+// proxy's rate limiter keys on the client's IP rather than one-bucket-per-
+// connection.
+func clientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
 func main() {
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+
+	cfg := &proxy.Config{
+		MetricsPath: "/metrics",
+		RateLimit: proxy.RateLimitConfig{
+			BytesPerSecond: 8 << 20, // 8 MiB/s per client per direction
+			Burst:          4 << 20,
+		},
+		// AuthTokensPath:        "/etc/ironrdp-proxy/auth-tokens.json",
+		// NegotiateWithServer:   true,
+		// RecordingPathTemplate: "/var/recordings/%s.rec",
+	}
+	if err := cfg.Init(mux); err != nil {
+		log.Fatal(err)
+	}
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./web/index.html")
 	})
 
-	http.HandleFunc("/iron-remote-gui.js", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/iron-remote-gui.js", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./web/node_modules/@devolutions/iron-remote-gui/iron-remote-gui.js")
 	})
 
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		upgrader := websocket.Upgrader{}
 
 		ws, err := upgrader.Upgrade(w, r, nil)
@@ -27,10 +55,10 @@ func main() {
 		}
 		defer ws.Close()
 
-		proxy.Handle(r.Context(), ws)
+		proxy.Handle(r.Context(), ws, cfg.Options(clientIP(r.RemoteAddr))...)
 	})
 
 	addr := ":4567"
 	log.Println("Listening on", addr)
-	http.ListenAndServe(addr, nil)
+	http.ListenAndServe(addr, mux)
 }